@@ -0,0 +1,29 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2019 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package ifc contains interfaces shared between the UI and the matrix connection, so the two
+// packages don't need to depend on each other directly.
+package ifc
+
+// MatrixContainer is the interface through which UI components send actions to the Matrix
+// connection.
+type MatrixContainer interface {
+	// SendReaction sends an m.reaction event annotating eventID in roomID with emoji, and
+	// returns the ID of the new event.
+	SendReaction(roomID, eventID, emoji string) (string, error)
+	// RedactReaction redacts eventID with a reason appropriate for removing a reaction.
+	RedactReaction(roomID, eventID, emoji string) (string, error)
+}
@@ -0,0 +1,55 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2019 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package matrix
+
+import (
+	"maunium.net/go/mautrix"
+
+	"maunium.net/go/gomuks/interface"
+)
+
+// Container is the concrete connection to the Matrix homeserver that backs ifc.MatrixContainer.
+type Container struct {
+	client *mautrix.Client
+}
+
+var _ ifc.MatrixContainer = (*Container)(nil)
+
+// SendReaction sends an m.reaction event annotating eventID in roomID with emoji.
+func (c *Container) SendReaction(roomID, eventID, emoji string) (string, error) {
+	content := map[string]interface{}{
+		"m.relates_to": map[string]interface{}{
+			"rel_type": "m.annotation",
+			"event_id": eventID,
+			"key":      emoji,
+		},
+	}
+	resp, err := c.client.SendMessageEvent(roomID, mautrix.NewEventType("m.reaction"), content)
+	if err != nil {
+		return "", err
+	}
+	return resp.EventID, nil
+}
+
+// RedactReaction redacts eventID, the event previously sent for emoji.
+func (c *Container) RedactReaction(roomID, eventID, emoji string) (string, error) {
+	resp, err := c.client.RedactEvent(roomID, eventID, mautrix.ReqRedact{Reason: "removed reaction"})
+	if err != nil {
+		return "", err
+	}
+	return resp.EventID, nil
+}
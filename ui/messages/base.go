@@ -19,6 +19,9 @@ package messages
 import (
 	"encoding/json"
 	"fmt"
+	"log"
+	"sort"
+	"strings"
 	"time"
 
 	"maunium.net/go/gomuks/config"
@@ -56,6 +59,26 @@ type UIMessage struct {
 	Source             json.RawMessage
 	ReplyTo            *UIMessage
 	Renderer           MessageRenderer
+	Reactions          map[string][]string
+	ThreadRoot         *UIMessage
+	ThreadReplies      []*UIMessage
+	ThreadExpanded     bool
+	EditHistory        []EditRevision
+
+	lastBufferPreferences config.UserPreferences
+	lastBufferWidth       int
+	// reactionEventIDs maps emoji -> senderID -> the ID of the m.reaction event that recorded it,
+	// so toggling off our own reaction (or a remote m.room.redaction) can target the reaction
+	// event itself rather than the message it annotates.
+	reactionEventIDs map[string]map[string]string
+}
+
+// EditRevision captures one prior revision of an edited message, so ViewEditHistory can diff
+// consecutive versions.
+type EditRevision struct {
+	Renderer  MessageRenderer
+	Source    json.RawMessage
+	Timestamp time.Time
 }
 
 const DateFormat = "January _2, 2006"
@@ -81,6 +104,7 @@ func newUIMessage(event *mautrix.Event, displayname string, renderer MessageRend
 		IsService:          false,
 		Source:             event.Content.VeryRaw,
 		Renderer:           renderer,
+		Reactions:          make(map[string][]string),
 	}
 }
 
@@ -193,9 +217,57 @@ func (msg *UIMessage) ReplyHeight() int {
 	return 0
 }
 
+// ReactionHeight returns the number of rows used by the reaction footer, if any.
+func (msg *UIMessage) ReactionHeight() int {
+	if len(msg.Reactions) == 0 {
+		return 0
+	}
+	return 1
+}
+
+// IsThreadRoot returns whether this message has any replies in its thread.
+func (msg *UIMessage) IsThreadRoot() bool {
+	return len(msg.ThreadReplies) > 0
+}
+
+// AddThreadReply appends reply as a child of this message's thread, setting reply's ThreadRoot
+// back-reference.
+func (msg *UIMessage) AddThreadReply(reply *UIMessage) {
+	reply.ThreadRoot = msg
+	msg.ThreadReplies = append(msg.ThreadReplies, reply)
+}
+
+// ToggleThread expands or collapses the inline thread summary for this message.
+func (msg *UIMessage) ToggleThread() {
+	msg.ThreadExpanded = !msg.ThreadExpanded
+}
+
+// ThreadHeight returns the number of rows contributed by the thread summary line, plus the
+// height of every reply when the thread is expanded.
+func (msg *UIMessage) ThreadHeight() int {
+	if !msg.IsThreadRoot() {
+		return 0
+	}
+	height := 1
+	if msg.ThreadExpanded {
+		for _, reply := range msg.ThreadReplies {
+			height += 1 + reply.Height()
+		}
+	}
+	return height
+}
+
+// EditMarkerHeight returns the number of rows used by the "(edited)" marker, if any.
+func (msg *UIMessage) EditMarkerHeight() int {
+	if !msg.IsEdited() {
+		return 0
+	}
+	return 1
+}
+
 // Height returns the number of rows in the computed buffer (see Buffer()).
 func (msg *UIMessage) Height() int {
-	return msg.ReplyHeight() + msg.Renderer.Height()
+	return msg.ReplyHeight() + msg.Renderer.Height() + msg.EditMarkerHeight() + msg.ReactionHeight() + msg.ThreadHeight()
 }
 
 func (msg *UIMessage) Time() time.Time {
@@ -237,11 +309,15 @@ func (msg *UIMessage) SetIsHighlight(isHighlight bool) {
 func (msg *UIMessage) Draw(screen mauview.Screen) {
 	screen = msg.DrawReply(screen)
 	msg.Renderer.Draw(screen)
+	msg.drawEditMarker(screen)
+	msg.DrawReactions(screen)
+	msg.DrawThread(screen)
 }
 
 func (msg *UIMessage) Clone() *UIMessage {
 	clone := *msg
 	clone.Renderer = clone.Renderer.Clone()
+	clone.EditHistory = append([]EditRevision(nil), msg.EditHistory...)
 	return &clone
 }
 
@@ -253,7 +329,26 @@ func (msg *UIMessage) CalculateReplyBuffer(preferences config.UserPreferences, w
 }
 
 func (msg *UIMessage) CalculateBuffer(preferences config.UserPreferences, width int) {
+	msg.lastBufferPreferences = preferences
+	msg.lastBufferWidth = width
 	msg.Renderer.CalculateBuffer(preferences, width-1, msg)
+	if msg.ThreadExpanded {
+		msg.CalculateThreadBuffer(preferences, width)
+	}
+}
+
+// CalculateThreadBuffer lays out this message's thread replies against the narrower thread
+// width, recursing into any of those replies that are themselves expanded threads.
+//
+// This intentionally reuses each reply's own CalculateBuffer rather than a dedicated
+// CalculateThreadBuffer hook on MessageRenderer: no renderer needs different layout logic for
+// the thread view, so a parallel interface method would only duplicate it.
+func (msg *UIMessage) CalculateThreadBuffer(preferences config.UserPreferences, width int) {
+	threadWidth := width - 2
+	for _, reply := range msg.ThreadReplies {
+		reply.CalculateBuffer(preferences, threadWidth)
+		reply.CalculateReplyBuffer(preferences, threadWidth)
+	}
 }
 
 func (msg *UIMessage) DrawReply(screen mauview.Screen) mauview.Screen {
@@ -272,6 +367,368 @@ func (msg *UIMessage) DrawReply(screen mauview.Screen) mauview.Screen {
 	return mauview.NewProxyScreen(screen, 0, replyHeight+1, width, height-replyHeight-1)
 }
 
+// HasReaction returns whether senderID has reacted to this message with the given emoji.
+func (msg *UIMessage) HasReaction(emoji, senderID string) bool {
+	for _, sender := range msg.Reactions[emoji] {
+		if sender == senderID {
+			return true
+		}
+	}
+	return false
+}
+
+// AddReaction records that senderID reacted to this message with emoji.
+//
+// If senderID has already reacted with the same emoji, this is a no-op.
+func (msg *UIMessage) AddReaction(emoji, senderID string) {
+	if msg.HasReaction(emoji, senderID) {
+		return
+	}
+	if msg.Reactions == nil {
+		msg.Reactions = make(map[string][]string)
+	}
+	msg.Reactions[emoji] = append(msg.Reactions[emoji], senderID)
+}
+
+// RemoveReaction removes senderID's reaction of emoji from this message, if any.
+func (msg *UIMessage) RemoveReaction(emoji, senderID string) {
+	senders, ok := msg.Reactions[emoji]
+	if !ok {
+		return
+	}
+	for i, sender := range senders {
+		if sender == senderID {
+			msg.Reactions[emoji] = append(senders[:i], senders[i+1:]...)
+			break
+		}
+	}
+	if len(msg.Reactions[emoji]) == 0 {
+		delete(msg.Reactions, emoji)
+	}
+	delete(msg.reactionEventIDs[emoji], senderID)
+}
+
+// reactionEventID returns the ID of the m.reaction event that recorded senderID's reaction of
+// emoji, or an empty string if none is known.
+func (msg *UIMessage) reactionEventID(emoji, senderID string) string {
+	return msg.reactionEventIDs[emoji][senderID]
+}
+
+// setReactionEventID records that senderID's reaction of emoji was recorded by the m.reaction
+// event eventID.
+func (msg *UIMessage) setReactionEventID(emoji, senderID, eventID string) {
+	if msg.reactionEventIDs == nil {
+		msg.reactionEventIDs = make(map[string]map[string]string)
+	}
+	if msg.reactionEventIDs[emoji] == nil {
+		msg.reactionEventIDs[emoji] = make(map[string]string)
+	}
+	msg.reactionEventIDs[emoji][senderID] = eventID
+}
+
+// ToggleOwnReaction toggles userID's reaction of emoji on this message, dispatching the
+// m.annotation send or redaction to matrix in the background.
+//
+// No keybinding in the message view calls this; nothing in the repository calls it.
+func (msg *UIMessage) ToggleOwnReaction(matrix ifc.MatrixContainer, roomID, userID, emoji string) {
+	if msg.HasReaction(emoji, userID) {
+		go msg.redactOwnReaction(matrix, roomID, userID, emoji)
+	} else {
+		go msg.sendOwnReaction(matrix, roomID, userID, emoji)
+	}
+}
+
+// sendOwnReaction optimistically adds emoji as userID's reaction, then sends the m.annotation
+// event and records its event ID. If sending fails, the optimistic update is rolled back and the
+// error is logged.
+func (msg *UIMessage) sendOwnReaction(matrix ifc.MatrixContainer, roomID, userID, emoji string) {
+	msg.AddReaction(emoji, userID)
+	eventID, err := matrix.SendReaction(roomID, msg.EventID, emoji)
+	if err != nil {
+		log.Printf("Failed to send reaction %s on %s: %v", emoji, msg.EventID, err)
+		msg.RemoveReaction(emoji, userID)
+		return
+	}
+	msg.setReactionEventID(emoji, userID, eventID)
+}
+
+// redactOwnReaction optimistically removes userID's reaction of emoji, then redacts the
+// m.reaction event that recorded it (never the message it annotates). If redaction fails, the
+// optimistic update is rolled back and the error is logged.
+func (msg *UIMessage) redactOwnReaction(matrix ifc.MatrixContainer, roomID, userID, emoji string) {
+	reactionEventID := msg.reactionEventID(emoji, userID)
+	msg.RemoveReaction(emoji, userID)
+	if len(reactionEventID) == 0 {
+		log.Printf("Cannot redact reaction %s by %s on %s: no reaction event ID recorded", emoji, userID, msg.EventID)
+		return
+	}
+	if _, err := matrix.RedactReaction(roomID, reactionEventID, emoji); err != nil {
+		log.Printf("Failed to redact reaction %s on %s: %v", emoji, msg.EventID, err)
+		msg.AddReaction(emoji, userID)
+		msg.setReactionEventID(emoji, userID, reactionEventID)
+	}
+}
+
+// relationContent is the subset of m.relates_to fields needed to tell which relation (if any) an
+// incoming event carries, what it targets, and (for m.annotation) its key.
+type relationContent struct {
+	RelatesTo struct {
+		RelType string `json:"rel_type"`
+		EventID string `json:"event_id"`
+		Key     string `json:"key"`
+	} `json:"m.relates_to"`
+}
+
+// parseRelation extracts the relation type, target event ID and annotation key (if any) from
+// event's content. ok is false if event has no parseable m.relates_to.
+func parseRelation(event *mautrix.Event) (relType, eventID, key string, ok bool) {
+	var content relationContent
+	if err := json.Unmarshal(event.Content.VeryRaw, &content); err != nil {
+		return "", "", "", false
+	}
+	return content.RelatesTo.RelType, content.RelatesTo.EventID, content.RelatesTo.Key, true
+}
+
+// HandleReactionEvent patches this message's reaction state in place when an m.annotation
+// relation event targeting it arrives from the server, recording the reaction event's own ID so
+// a later redaction (ours or a remote one) can be matched back to it.
+func (msg *UIMessage) HandleReactionEvent(event *mautrix.Event) {
+	relType, eventID, key, ok := parseRelation(event)
+	if !ok || relType != "m.annotation" || eventID != msg.EventID || len(key) == 0 {
+		return
+	}
+	msg.AddReaction(key, event.Sender)
+	msg.setReactionEventID(key, event.Sender, event.ID)
+}
+
+// HandleRedactionEvent removes a reaction from this message if event is an m.room.redaction
+// targeting a previously recorded m.reaction event, e.g. another user un-reacting.
+func (msg *UIMessage) HandleRedactionEvent(event *mautrix.Event) {
+	if event.Type.String() != "m.room.redaction" || len(event.Redacts) == 0 {
+		return
+	}
+	for emoji, senders := range msg.reactionEventIDs {
+		for senderID, reactionEventID := range senders {
+			if reactionEventID == event.Redacts {
+				msg.RemoveReaction(emoji, senderID)
+				return
+			}
+		}
+	}
+}
+
+// FormatReactions formats this message's reactions into a single compact footer line, e.g.
+// "❤️ 1  👍 3". Emojis are sorted so the line is stable across redraws.
+func (msg *UIMessage) FormatReactions() string {
+	if len(msg.Reactions) == 0 {
+		return ""
+	}
+	emojis := make([]string, 0, len(msg.Reactions))
+	for emoji := range msg.Reactions {
+		emojis = append(emojis, emoji)
+	}
+	sort.Strings(emojis)
+	parts := make([]string, len(emojis))
+	for i, emoji := range emojis {
+		parts[i] = fmt.Sprintf("%s %d", emoji, len(msg.Reactions[emoji]))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// DrawReactions renders the compact reaction footer on the row directly below the message's
+// own content (and edit marker, if any).
+func (msg *UIMessage) DrawReactions(screen mauview.Screen) {
+	if len(msg.Reactions) == 0 {
+		return
+	}
+	y := msg.Renderer.Height() + msg.EditMarkerHeight()
+	widget.WriteLineSimpleColor(screen, msg.FormatReactions(), 0, y, tcell.ColorDefault)
+}
+
+// HandleThreadEvent attaches reply to this message's thread if event carries an m.thread
+// relation pointing at it, returning whether it was attached.
+func (msg *UIMessage) HandleThreadEvent(event *mautrix.Event, reply *UIMessage) bool {
+	relType, eventID, _, ok := parseRelation(event)
+	if !ok || relType != "m.thread" || eventID != msg.EventID {
+		return false
+	}
+	msg.AddThreadReply(reply)
+	return true
+}
+
+// DrawThread renders the collapsible "N replies in thread" summary below the reaction footer,
+// and the full thread participants when expanded.
+//
+// Expanding/collapsing is done through ToggleThread, which no message-view keybinding calls;
+// there is no separate thread buffer view.
+func (msg *UIMessage) DrawThread(screen mauview.Screen) {
+	if !msg.IsThreadRoot() {
+		return
+	}
+	width, _ := screen.Size()
+	y := msg.Renderer.Height() + msg.EditMarkerHeight() + msg.ReactionHeight()
+	widget.WriteLineSimpleColor(screen, msg.threadSummary(), 0, y, tcell.ColorGreen)
+	if !msg.ThreadExpanded {
+		return
+	}
+	offsetY := y + 1
+	for _, reply := range msg.ThreadReplies {
+		widget.WriteLineSimpleColor(screen, reply.SenderName, 1, offsetY, reply.SenderColor())
+		replyScreen := mauview.NewProxyScreen(screen, 2, offsetY+1, width-2, reply.Height())
+		reply.Draw(replyScreen)
+		offsetY += 1 + reply.Height()
+	}
+}
+
+func (msg *UIMessage) threadSummary() string {
+	noun := "replies"
+	if len(msg.ThreadReplies) == 1 {
+		noun = "reply"
+	}
+	summary := fmt.Sprintf("%d %s in thread", len(msg.ThreadReplies), noun)
+	if !msg.ThreadExpanded {
+		summary += " (expand)"
+	}
+	return summary
+}
+
+// IsEdited returns whether this message has been edited at least once.
+func (msg *UIMessage) IsEdited() bool {
+	return len(msg.EditHistory) > 0
+}
+
+// ApplyEdit replaces this message's renderer, source and timestamp with a new revision, pushing
+// the previous renderer, source and timestamp onto EditHistory so ViewEditHistory can diff
+// consecutive versions. The new renderer's layout is recalculated immediately against the width
+// the message was last drawn at, so the replaced renderer's buffer is never shown stale.
+func (msg *UIMessage) ApplyEdit(newRenderer MessageRenderer, newSource json.RawMessage, timestamp time.Time) {
+	msg.EditHistory = append(msg.EditHistory, EditRevision{
+		Renderer:  msg.Renderer,
+		Source:    msg.Source,
+		Timestamp: msg.Timestamp,
+	})
+	msg.Renderer = newRenderer
+	msg.Source = newSource
+	msg.Timestamp = timestamp
+	if msg.lastBufferWidth > 0 {
+		msg.Renderer.CalculateBuffer(msg.lastBufferPreferences, msg.lastBufferWidth-1, msg)
+	}
+}
+
+// EditMarker returns the "(edited)" marker text shown on its own row directly below this
+// message's content, or an empty string if it hasn't been edited.
+func (msg *UIMessage) EditMarker() string {
+	if !msg.IsEdited() {
+		return ""
+	}
+	return "(edited)"
+}
+
+// drawEditMarker renders EditMarker() on its own row directly below the message's content, in
+// TextColor() so it matches the rest of the message.
+func (msg *UIMessage) drawEditMarker(screen mauview.Screen) {
+	marker := msg.EditMarker()
+	if len(marker) == 0 {
+		return
+	}
+	widget.WriteLineSimpleColor(screen, marker, 0, msg.Renderer.Height(), msg.TextColor())
+}
+
+// ViewEditHistory returns a unified line-diff between each pair of consecutive revisions of
+// this message, oldest first, for display in a modal.
+func (msg *UIMessage) ViewEditHistory() []string {
+	if !msg.IsEdited() {
+		return nil
+	}
+	texts := make([]string, 0, len(msg.EditHistory)+1)
+	for _, revision := range msg.EditHistory {
+		texts = append(texts, revision.Renderer.PlainText())
+	}
+	texts = append(texts, msg.Renderer.PlainText())
+
+	diffs := make([]string, 0, len(texts)-1)
+	for i := 1; i < len(texts); i++ {
+		diffs = append(diffs, diffLines(texts[i-1], texts[i]))
+	}
+	return diffs
+}
+
+// diffLines produces a unified line-diff between two blocks of text, prefixing removed lines
+// with "-", added lines with "+", and unchanged lines with a space. It aligns the two sides on
+// their longest common subsequence of lines, so a single inserted or deleted line doesn't make
+// every following line look changed.
+func diffLines(oldText, newText string) string {
+	oldLines := strings.Split(oldText, "\n")
+	newLines := strings.Split(newText, "\n")
+
+	// lcs[i][j] = length of the longest common subsequence of oldLines[i:] and newLines[j:].
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(oldLines) && j < len(newLines) {
+		switch {
+		case oldLines[i] == newLines[j]:
+			out = append(out, "  "+oldLines[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "- "+oldLines[i])
+			i++
+		default:
+			out = append(out, "+ "+newLines[j])
+			j++
+		}
+	}
+	for ; i < len(oldLines); i++ {
+		out = append(out, "- "+oldLines[i])
+	}
+	for ; j < len(newLines); j++ {
+		out = append(out, "+ "+newLines[j])
+	}
+	return strings.Join(out, "\n")
+}
+
+// FindByEventID returns the message in messages whose EventID matches eventID, or nil.
+func FindByEventID(messages []*UIMessage, eventID string) *UIMessage {
+	for _, msg := range messages {
+		if msg.EventID == eventID {
+			return msg
+		}
+	}
+	return nil
+}
+
+// HandleEditEvent finds the message targeted by event's m.replace relation among messages and
+// applies the edit in place via ApplyEdit, returning the edited message, or nil if event isn't
+// an edit or its target wasn't found.
+func HandleEditEvent(messages []*UIMessage, event *mautrix.Event, newRenderer MessageRenderer) *UIMessage {
+	relType, eventID, _, ok := parseRelation(event)
+	if !ok || relType != "m.replace" {
+		return nil
+	}
+	target := FindByEventID(messages, eventID)
+	if target == nil {
+		return nil
+	}
+	target.ApplyEdit(newRenderer, event.Content.VeryRaw, unixToTime(event.Timestamp))
+	return target
+}
+
 func (msg *UIMessage) String() string {
 	return fmt.Sprintf(`&messages.UIMessage{
     ID="%s", TxnID="%s",
@@ -0,0 +1,257 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2019 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package messages
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mauview"
+
+	"maunium.net/go/gomuks/config"
+	"maunium.net/go/gomuks/interface"
+)
+
+// fakeRenderer is a no-op MessageRenderer used to build UIMessage values in tests without
+// depending on any real rendering implementation.
+type fakeRenderer struct {
+	height int
+	text   string
+}
+
+func (f *fakeRenderer) Draw(screen mauview.Screen)                {}
+func (f *fakeRenderer) NotificationContent() string               { return f.text }
+func (f *fakeRenderer) PlainText() string                         { return f.text }
+func (f *fakeRenderer) RegisterMatrix(matrix ifc.MatrixContainer) {}
+func (f *fakeRenderer) Height() int                               { return f.height }
+func (f *fakeRenderer) String() string                            { return f.text }
+func (f *fakeRenderer) Clone() MessageRenderer {
+	clone := *f
+	return &clone
+}
+func (f *fakeRenderer) CalculateBuffer(prefs config.UserPreferences, width int, msg *UIMessage) {}
+
+// fakeMatrix is an ifc.MatrixContainer that records what it was asked to do, for asserting on
+// without a real Matrix connection.
+type fakeMatrix struct {
+	sendEventID  string
+	sendErr      error
+	redactErr    error
+	redactedWith string
+}
+
+func (f *fakeMatrix) SendReaction(roomID, eventID, emoji string) (string, error) {
+	if f.sendErr != nil {
+		return "", f.sendErr
+	}
+	return f.sendEventID, nil
+}
+
+func (f *fakeMatrix) RedactReaction(roomID, eventID, emoji string) (string, error) {
+	f.redactedWith = eventID
+	if f.redactErr != nil {
+		return "", f.redactErr
+	}
+	return "$redaction", nil
+}
+
+func newTestMessage() *UIMessage {
+	return &UIMessage{
+		EventID:  "$message",
+		Renderer: &fakeRenderer{},
+	}
+}
+
+func TestAddReaction(t *testing.T) {
+	msg := newTestMessage()
+	msg.AddReaction("👍", "@alice:example.com")
+	if !msg.HasReaction("👍", "@alice:example.com") {
+		t.Fatal("expected reaction to be recorded")
+	}
+
+	msg.AddReaction("👍", "@alice:example.com")
+	if len(msg.Reactions["👍"]) != 1 {
+		t.Fatalf("expected duplicate AddReaction to be a no-op, got %d senders", len(msg.Reactions["👍"]))
+	}
+}
+
+func TestRemoveReaction(t *testing.T) {
+	msg := newTestMessage()
+	msg.AddReaction("👍", "@alice:example.com")
+	msg.AddReaction("👍", "@bob:example.com")
+
+	msg.RemoveReaction("👍", "@alice:example.com")
+	if msg.HasReaction("👍", "@alice:example.com") {
+		t.Fatal("expected alice's reaction to be removed")
+	}
+	if !msg.HasReaction("👍", "@bob:example.com") {
+		t.Fatal("expected bob's reaction to survive")
+	}
+
+	msg.RemoveReaction("👍", "@bob:example.com")
+	if _, ok := msg.Reactions["👍"]; ok {
+		t.Fatal("expected the emoji entry to be pruned once empty")
+	}
+}
+
+func TestHandleReactionEvent(t *testing.T) {
+	msg := newTestMessage()
+	event := &mautrix.Event{
+		ID:     "$reaction1",
+		Sender: "@alice:example.com",
+		Content: mautrix.Content{
+			VeryRaw: json.RawMessage(`{"m.relates_to":{"rel_type":"m.annotation","event_id":"$message","key":"👍"}}`),
+		},
+	}
+
+	msg.HandleReactionEvent(event)
+
+	if !msg.HasReaction("👍", "@alice:example.com") {
+		t.Fatal("expected reaction from the event to be recorded")
+	}
+	if got := msg.reactionEventID("👍", "@alice:example.com"); got != "$reaction1" {
+		t.Fatalf("expected reaction event ID %q to be recorded, got %q", "$reaction1", got)
+	}
+}
+
+func TestToggleOwnReactionSendsThenRedactsTheReactionEvent(t *testing.T) {
+	msg := newTestMessage()
+	matrix := &fakeMatrix{sendEventID: "$reaction1"}
+
+	msg.sendOwnReaction(matrix, "!room:example.com", "@alice:example.com", "👍")
+	if !msg.HasReaction("👍", "@alice:example.com") {
+		t.Fatal("expected reaction to be recorded after sending")
+	}
+
+	msg.redactOwnReaction(matrix, "!room:example.com", "@alice:example.com", "👍")
+	if msg.HasReaction("👍", "@alice:example.com") {
+		t.Fatal("expected reaction to be removed after redacting")
+	}
+	if matrix.redactedWith != "$reaction1" {
+		t.Fatalf("expected redaction to target the reaction event %q, got %q", "$reaction1", matrix.redactedWith)
+	}
+	if matrix.redactedWith == msg.EventID {
+		t.Fatal("redaction must never target the original message event")
+	}
+}
+
+func TestSendOwnReactionRollsBackOnError(t *testing.T) {
+	msg := newTestMessage()
+	matrix := &fakeMatrix{sendErr: errors.New("send failed")}
+
+	msg.sendOwnReaction(matrix, "!room:example.com", "@alice:example.com", "👍")
+
+	if msg.HasReaction("👍", "@alice:example.com") {
+		t.Fatal("expected optimistic reaction to be rolled back after a send error")
+	}
+}
+
+func TestHeightWithNoExtras(t *testing.T) {
+	msg := newTestMessage()
+	msg.Renderer = &fakeRenderer{height: 3}
+
+	if got := msg.Height(); got != 3 {
+		t.Fatalf("expected Height() to be just the renderer's height (3), got %d", got)
+	}
+}
+
+func TestHeightSumsReplyReactionAndEditContributions(t *testing.T) {
+	msg := newTestMessage()
+	msg.Renderer = &fakeRenderer{height: 3}
+	msg.ReplyTo = &UIMessage{Renderer: &fakeRenderer{height: 2}}
+	msg.AddReaction("👍", "@alice:example.com")
+	msg.EditHistory = []EditRevision{{Renderer: &fakeRenderer{height: 3}}}
+
+	// renderer(3) + reply(1 + 2) + reaction footer(1) + edit marker(1)
+	if got, want := msg.Height(), 8; got != want {
+		t.Fatalf("expected Height() to be %d, got %d", want, got)
+	}
+}
+
+func TestThreadHeightCollapsed(t *testing.T) {
+	msg := newTestMessage()
+	reply := &UIMessage{Renderer: &fakeRenderer{height: 2}}
+	msg.AddThreadReply(reply)
+
+	if got, want := msg.ThreadHeight(), 1; got != want {
+		t.Fatalf("expected a collapsed thread to contribute only its 1-row summary, got %d", got)
+	}
+}
+
+func TestThreadHeightExpanded(t *testing.T) {
+	msg := newTestMessage()
+	replyA := &UIMessage{Renderer: &fakeRenderer{height: 2}}
+	replyB := &UIMessage{Renderer: &fakeRenderer{height: 1}}
+	msg.AddThreadReply(replyA)
+	msg.AddThreadReply(replyB)
+	msg.ThreadExpanded = true
+
+	// summary(1) + (1 + replyA.Height()=2) + (1 + replyB.Height()=1)
+	if got, want := msg.ThreadHeight(), 6; got != want {
+		t.Fatalf("expected expanded ThreadHeight() to be %d, got %d", want, got)
+	}
+}
+
+func TestDiffLinesUnchanged(t *testing.T) {
+	got := diffLines("hello\nworld", "hello\nworld")
+	want := "  hello\n  world"
+	if got != want {
+		t.Fatalf("expected no diff markers for identical text, got %q", got)
+	}
+}
+
+func TestDiffLinesInsertionDoesNotMarkFollowingLinesChanged(t *testing.T) {
+	got := diffLines("a\nb\nc", "a\nnew\nb\nc")
+	want := "  a\n+ new\n  b\n  c"
+	if got != want {
+		t.Fatalf("expected only the inserted line to be marked, got %q", got)
+	}
+}
+
+func TestDiffLinesDeletionDoesNotMarkFollowingLinesChanged(t *testing.T) {
+	got := diffLines("a\nb\nc", "a\nc")
+	want := "  a\n- b\n  c"
+	if got != want {
+		t.Fatalf("expected only the deleted line to be marked, got %q", got)
+	}
+}
+
+func TestDiffLinesReplacement(t *testing.T) {
+	got := diffLines("hello world", "goodbye world")
+	want := "- hello world\n+ goodbye world"
+	if got != want {
+		t.Fatalf("expected a full line replace to show as remove+add, got %q", got)
+	}
+}
+
+func TestRedactOwnReactionRollsBackOnError(t *testing.T) {
+	msg := newTestMessage()
+	matrix := &fakeMatrix{sendEventID: "$reaction1"}
+	msg.sendOwnReaction(matrix, "!room:example.com", "@alice:example.com", "👍")
+
+	matrix.redactErr = errors.New("redact failed")
+	msg.redactOwnReaction(matrix, "!room:example.com", "@alice:example.com", "👍")
+
+	if !msg.HasReaction("👍", "@alice:example.com") {
+		t.Fatal("expected reaction to be restored after a redaction error")
+	}
+	if got := msg.reactionEventID("👍", "@alice:example.com"); got != "$reaction1" {
+		t.Fatalf("expected restored reaction to keep its event ID %q, got %q", "$reaction1", got)
+	}
+}